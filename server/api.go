@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/synacor/sibyl/game"
+)
+
+const apiRoomsPath = "/api/v1/rooms"
+
+// apiError is the JSON body returned for non-2xx API responses.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiCreateRoomRequest is the body accepted by POST /api/v1/rooms.
+type apiCreateRoomRequest struct {
+	Room string `json:"room"`
+	Deck string `json:"deck"`
+}
+
+// apiCreateRoomResponse is returned by POST /api/v1/rooms.
+type apiCreateRoomResponse struct {
+	Room  string `json:"room"`
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// apiRoomSummary is one entry in the GET /api/v1/rooms listing.
+type apiRoomSummary struct {
+	Room    string `json:"room"`
+	Clients int    `json:"clients"`
+}
+
+// apiRoomState is the detailed state returned by GET /api/v1/rooms/{room}.
+type apiRoomState struct {
+	Room         string         `json:"room"`
+	Deck         string         `json:"deck"`
+	Topic        string         `json:"topic"`
+	Revealed     bool           `json:"revealed"`
+	Participants []string       `json:"participants"`
+	Votes        map[string]int `json:"votes,omitempty"`
+}
+
+// apiRoomsHandler handles the /api/v1/rooms collection: creating rooms and
+// listing the active ones.
+func (s *Server) apiRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	switch strings.ToUpper(r.Method) {
+	case http.MethodPost:
+		s.apiCreateRoom(w, r)
+	case http.MethodGet:
+		s.apiListRooms(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// apiRoomHandler handles the /api/v1/rooms/{room} item: fetching state and
+// force-destroying a room.
+func (s *Server) apiRoomHandler(w http.ResponseWriter, r *http.Request) {
+	room := strings.TrimPrefix(r.URL.Path, apiRoomsPath+"/")
+	if room == "" {
+		writeAPIError(w, http.StatusNotFound, "room not found")
+		return
+	}
+
+	switch strings.ToUpper(r.Method) {
+	case http.MethodGet:
+		s.apiGetRoom(w, r, room)
+	case http.MethodDelete:
+		s.apiDeleteRoom(w, r, room)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) apiCreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req apiCreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "could not decode request body")
+		return
+	}
+
+	if !game.RoomNameIsValid(req.Room) {
+		writeAPIError(w, http.StatusBadRequest, game.RoomNameValidDescription)
+		return
+	}
+
+	g, err := s.createGameIfNotExists(req.Room, req.Deck)
+	if err != nil {
+		log.WithFields(log.Fields{"room": req.Room}).Errorf("could not create room via api: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "could not create room")
+		return
+	}
+
+	writeAPIJSON(w, http.StatusCreated, &apiCreateRoomResponse{
+		Room:  g.Room,
+		Token: g.Token,
+		URL:   "/r/" + g.Room,
+	})
+}
+
+func (s *Server) apiListRooms(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizedAdmin(r) {
+		writeAPIError(w, http.StatusUnauthorized, "missing or invalid admin token")
+		return
+	}
+
+	s.safeGames.mutex.RLock()
+	rooms := make([]apiRoomSummary, 0, len(s.safeGames.games))
+	for _, g := range s.safeGames.games {
+		rooms = append(rooms, apiRoomSummary{Room: g.Room, Clients: g.RegisteredClientsCount()})
+	}
+	s.safeGames.mutex.RUnlock()
+
+	writeAPIJSON(w, http.StatusOK, rooms)
+}
+
+// apiGetRoom returns a room's current state. Votes are blind estimation data
+// the same way the websocket protocol withholds them pre-reveal, so they're
+// only included once the room has been revealed or the caller is an admin.
+func (s *Server) apiGetRoom(w http.ResponseWriter, r *http.Request, room string) {
+	g := s.getGameByRoom(room)
+	if g == nil {
+		writeAPIError(w, http.StatusNotFound, "room not found")
+		return
+	}
+
+	state := &apiRoomState{
+		Room:         g.Room,
+		Deck:         g.DeckName(),
+		Topic:        g.Topic(),
+		Revealed:     g.Revealed(),
+		Participants: g.ParticipantNames(),
+	}
+
+	if g.Revealed() || s.authorizedAdmin(r) {
+		state.Votes = g.Selections()
+	}
+
+	writeAPIJSON(w, http.StatusOK, state)
+}
+
+// apiDeleteRoom force-destroys a room. This is the most destructive endpoint
+// in the API, so it's gated behind the admin token the same as the room
+// listing and pre-reveal votes.
+func (s *Server) apiDeleteRoom(w http.ResponseWriter, r *http.Request, room string) {
+	if !s.authorizedAdmin(r) {
+		writeAPIError(w, http.StatusUnauthorized, "missing or invalid admin token")
+		return
+	}
+
+	g := s.getGameByRoom(room)
+	if g == nil {
+		writeAPIError(w, http.StatusNotFound, "room not found")
+		return
+	}
+
+	s.destroyGame <- g
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizedAdmin reports whether r carries the admin token configured via
+// the "admin_token" viper key. If no admin token is configured, admin-only
+// endpoints are disabled entirely.
+func (s *Server) authorizedAdmin(r *http.Request) bool {
+	adminToken := viper.GetString("admin_token")
+	if adminToken == "" {
+		return false
+	}
+
+	return r.Header.Get("Authorization") == "Bearer "+adminToken
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Errorf("could not encode api response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, &apiError{Error: message})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/synacor/sibyl/game"
+)
+
+func TestAuthorizedAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		configured    string
+		authorization string
+		want          bool
+	}{
+		{name: "no admin token configured disables admin access", configured: "", authorization: "Bearer anything", want: false},
+		{name: "matching bearer token is authorized", configured: "s3cr3t", authorization: "Bearer s3cr3t", want: true},
+		{name: "mismatched bearer token is rejected", configured: "s3cr3t", authorization: "Bearer wrong", want: false},
+		{name: "missing authorization header is rejected", configured: "s3cr3t", authorization: "", want: false},
+		{name: "non-bearer scheme is rejected", configured: "s3cr3t", authorization: "Basic s3cr3t", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("admin_token", tt.configured)
+			defer viper.Set("admin_token", "")
+
+			s := &Server{}
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/rooms", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			if got := s.authorizedAdmin(r); got != tt.want {
+				t.Errorf("authorizedAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiDeleteRoomRequiresAdminToken(t *testing.T) {
+	viper.Set("admin_token", "s3cr3t")
+	defer viper.Set("admin_token", "")
+
+	s := &Server{safeGames: &safeGames{games: make(map[string]*game.Game), mutex: &sync.RWMutex{}}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, apiRoomsPath+"/some-room", nil)
+	s.apiDeleteRoom(w, r, "some-room")
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("apiDeleteRoom without an admin token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
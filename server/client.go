@@ -0,0 +1,241 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gorilla/websocket"
+	"github.com/synacor/sibyl/game"
+)
+
+// UsernameMaxLength is the maximum length allowed for a client's display name.
+const UsernameMaxLength = 32
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+)
+
+// clientMessageType identifies the kind of frame a Client writes out over
+// its connection, alongside the regular game state updates.
+type clientMessageType string
+
+const clientMessageTypeIdentity clientMessageType = "identity"
+const clientMessageTypeShutdown clientMessageType = "server_shutdown"
+
+// clientIdentityMessage tells the browser which resume token to remember so
+// a reconnect can rebind to the same Client instead of being treated as a
+// new participant.
+type clientIdentityMessage struct {
+	Type        clientMessageType `json:"type"`
+	ClientToken string            `json:"clientToken"`
+}
+
+// clientShutdownMessage warns the browser that the server is about to close
+// its connection so it can show a friendlier message than a dropped socket.
+type clientShutdownMessage struct {
+	Type               clientMessageType `json:"type"`
+	Reason             string            `json:"reason"`
+	ReconnectAfterSecs int               `json:"reconnectAfterSecs"`
+}
+
+// Client represents a single websocket connection into a room. A Client
+// outlives any one connection: a resumed client keeps its ID/Token/name but
+// gets a new Conn and send channel each time it (re)connects.
+type Client struct {
+	Game  *game.Game
+	ID    int
+	Token string
+
+	nameMutex sync.RWMutex
+	name      string
+
+	connMutex sync.Mutex
+	Conn      *websocket.Conn
+	send      chan []byte
+}
+
+// NewClient returns a new *Client bound to g and conn, with id as its
+// participant ID and a freshly generated resume token.
+func NewClient(g *game.Game, conn *websocket.Conn, id int) *Client {
+	return &Client{
+		Game:  g,
+		Conn:  conn,
+		ID:    id,
+		Token: newClientToken(),
+		send:  make(chan []byte, 16),
+	}
+}
+
+// connection returns the Client's current Conn and send channel as a unit,
+// so callers never pair a stale channel with a fresh connection (or
+// vice versa) across a Rebind.
+func (c *Client) connection() (*websocket.Conn, chan []byte) {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	return c.Conn, c.send
+}
+
+// newClientToken generates a random resume token suitable for a client to
+// store in localStorage and present on reconnect.
+func newClientToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("could not generate client token: %v", err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Name returns the client's current display name.
+func (c *Client) Name() string {
+	c.nameMutex.RLock()
+	defer c.nameMutex.RUnlock()
+
+	return c.name
+}
+
+// SetName sets the client's display name, truncating it to UsernameMaxLength.
+func (c *Client) SetName(name string) {
+	if len(name) > UsernameMaxLength {
+		name = name[:UsernameMaxLength]
+	}
+
+	c.nameMutex.Lock()
+	c.name = name
+	c.nameMutex.Unlock()
+}
+
+// Rebind swaps in a new connection and a fresh send channel for a resumed
+// client, closing the previous connection so the old socket doesn't linger
+// as a ghost participant. A fresh send channel is required because the
+// previous ReadPump closes the old one on exit, and a closed channel can't
+// be reused by the new WritePump/ReadPump pair.
+func (c *Client) Rebind(conn *websocket.Conn) {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	if c.Conn != nil {
+		c.Conn.Close()
+	}
+
+	c.Conn = conn
+	c.send = make(chan []byte, 16)
+}
+
+// sendIdentity writes the client's resume token to the connection so the
+// browser can persist it for reconnects.
+func (c *Client) sendIdentity(send chan []byte) {
+	b, err := json.Marshal(&clientIdentityMessage{Type: clientMessageTypeIdentity, ClientToken: c.Token})
+	if err != nil {
+		log.Errorf("could not marshal identity message: %v", err)
+		return
+	}
+
+	select {
+	case send <- b:
+	default:
+		log.WithFields(log.Fields{"client": c.ID}).Warn("send buffer full, dropping identity message")
+	}
+}
+
+// WritePump pumps game state updates and control frames to the websocket
+// connection. It runs in its own goroutine, one per connection, and exits
+// once that connection's generation ends (write error, or server shutdown).
+func (c *Client) WritePump(s *Server) {
+	conn, send := c.connection()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	c.sendIdentity(send)
+
+	for {
+		select {
+		case msg, ok := <-send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-s.shutdownCtx.Done():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			return
+		}
+	}
+}
+
+// ReadPump reads WsRequests off the websocket connection and hands them to
+// s.HandleWsRequest. It blocks until the connection is closed, and runs on
+// the goroutine that called wsHandler.
+func (c *Client) ReadPump(s *Server) {
+	conn, send := c.connection()
+	defer close(send)
+
+	// done lets this pump's shutdown-watcher goroutine exit on a normal
+	// disconnect instead of leaking until the process-wide shutdownCtx
+	// eventually fires.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-s.shutdownCtx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		var req WsRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			log.WithFields(log.Fields{"client": c.ID}).Errorf("could not unmarshal ws request: %v", err)
+			continue
+		}
+
+		s.HandleWsRequest(c, &req)
+	}
+}
+
+// Write enqueues msg to be sent to the client asynchronously, on whichever
+// connection generation is current. Game state broadcasts use this rather
+// than writing to the connection directly.
+func (c *Client) Write(msg []byte) {
+	_, send := c.connection()
+
+	select {
+	case send <- msg:
+	default:
+		log.WithFields(log.Fields{"client": c.ID}).Warn("send buffer full, dropping message")
+	}
+}
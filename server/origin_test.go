@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		debug          bool
+		host           string
+		origin         string
+		want           bool
+	}{
+		{name: "no origin header is allowed", host: "sibyl.example.com", origin: "", want: true},
+		{name: "same-origin is allowed with an empty allow-list", host: "sibyl.example.com", origin: "https://sibyl.example.com", want: true},
+		{name: "cross-origin is rejected with an empty allow-list", host: "sibyl.example.com", origin: "https://evil.example.com", want: false},
+		{name: "cross-origin matching an exact allow-list entry", allowedOrigins: []string{"allowed.example.com"}, host: "sibyl.example.com", origin: "https://allowed.example.com", want: true},
+		{name: "cross-origin not matching the allow-list", allowedOrigins: []string{"allowed.example.com"}, host: "sibyl.example.com", origin: "https://other.example.com", want: false},
+		{name: "allow-list glob pattern matches subdomain", allowedOrigins: []string{"*.example.com"}, host: "sibyl.example.com", origin: "https://app.example.com", want: true},
+		{name: "wildcard allows any origin", allowedOrigins: []string{"*"}, host: "sibyl.example.com", origin: "https://anything.invalid", want: true},
+		{name: "unparseable origin is rejected", host: "sibyl.example.com", origin: "://not-a-url", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{allowedOrigins: tt.allowedOrigins, debug: tt.debug}
+
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			r.Host = tt.host
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			if got := s.checkOrigin(r); got != tt.want {
+				t.Errorf("checkOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -9,10 +10,12 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -24,6 +27,11 @@ import (
 
 const defaultTemplatesDir = "./templates"
 const defaultStaticDir = "./static"
+const defaultShutdownTimeout = 10 * time.Second
+
+// allowedOriginsWildcard, when present in the configured allow-list, disables
+// origin checking entirely. Intended for local development only.
+const allowedOriginsWildcard = "*"
 
 // WsRequestAction is a type for representing a web socket action
 type WsRequestAction string
@@ -36,6 +44,8 @@ const (
 	WsRequestActionDeck                       = "deck"
 	WsRequestActionTopic                      = "topic"
 	WsRequestActionUsername                   = "username"
+	WsRequestActionChat                       = "chat"
+	WsRequestActionReaction                   = "reaction"
 )
 
 // WsRequest is data that was read from a web socket connection
@@ -55,12 +65,18 @@ type safeGames struct {
 
 // Server is the main object that can be used to return an *http.ServeMux object.
 type Server struct {
-	templatesDir string
-	staticDir    string
-	templates    map[string]*template.Template
-	debug        bool
-	destroyGame  chan *game.Game
-	safeGames    *safeGames
+	templatesDir   string
+	staticDir      string
+	templates      map[string]*template.Template
+	debug          bool
+	destroyGame    chan *game.Game
+	safeGames      *safeGames
+	allowedOrigins []string
+	upgrader       websocket.Upgrader
+	store          Store
+
+	shutdownCtx    context.Context
+	shutdownCancel context.CancelFunc
 }
 
 type templateLoader struct {
@@ -68,11 +84,6 @@ type templateLoader struct {
 	baseTemplate *template.Template
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
-
 type indexTemplateValues struct {
 	RoomNameMaxLength int
 	Error             string
@@ -87,18 +98,25 @@ type roomTemplateValues struct {
 	TopicMaxLength    int
 	Username          string
 	UsernameMaxLength int
+	ChatHistoryJSON   template.JS
 }
 
 func init() {
 	viper.SetDefault("templates_dir", defaultTemplatesDir)
 	viper.SetDefault("static_dir", defaultStaticDir)
+	viper.SetDefault("allowed_origins", []string{})
+	viper.SetDefault("store_dir", defaultStoreDir)
+	viper.SetDefault("shutdown_timeout", defaultShutdownTimeout)
 	viper.BindEnv("debug")
+	viper.BindEnv("allowed_origins", "SIBYL_ALLOWED_ORIGINS")
+	viper.BindEnv("admin_token", "SIBYL_ADMIN_TOKEN")
 }
 
 // New returns a new *Server object
 func New() *Server {
 	templatesDir := viper.GetString("templates_dir")
 	t := newTemplateLoader(templatesDir, "template.html")
+	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
 	c := &Server{
 		safeGames: &safeGames{
 			games: make(map[string]*game.Game),
@@ -106,18 +124,113 @@ func New() *Server {
 		},
 		destroyGame: make(chan *game.Game),
 
-		templatesDir: templatesDir,
-		staticDir:    viper.GetString("static_dir"),
-		debug:        viper.GetBool("debug"),
+		shutdownCtx:    shutdownCtx,
+		shutdownCancel: shutdownCancel,
+
+		templatesDir:   templatesDir,
+		staticDir:      viper.GetString("static_dir"),
+		debug:          viper.GetBool("debug"),
+		allowedOrigins: allowedOrigins(),
 		templates: map[string]*template.Template{
 			"index": t.loadTemplate("index.html"),
 			"room":  t.loadTemplate("room.html"),
 		},
 	}
+	c.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     c.checkOrigin,
+	}
+
+	store, err := NewFileStore(viper.GetString("store_dir"))
+	if err != nil {
+		log.Errorf("could not create file store, room state will not persist: %v", err)
+	} else {
+		c.store = store
+		c.rehydrateRooms()
+	}
 
 	return c
 }
 
+// rehydrateRooms loads every room known to the store into memory so that
+// existing /r/<room> and /ws traffic continues to work across a redeploy.
+func (s *Server) rehydrateRooms() {
+	rooms, err := s.store.Rooms()
+	if err != nil {
+		log.Errorf("could not list rooms in store: %v", err)
+		return
+	}
+
+	for _, room := range rooms {
+		if _, err := s.loadGame(room); err != nil {
+			log.WithFields(log.Fields{"room": room}).Errorf("could not rehydrate room: %v", err)
+			continue
+		}
+
+		log.WithFields(log.Fields{"room": room}).Info("room rehydrated from store")
+	}
+}
+
+// allowedOrigins reads the configured allow-list from viper, splitting the
+// SIBYL_ALLOWED_ORIGINS env var (bound to "allowed_origins") on commas since
+// it arrives as a single string rather than a native list.
+func allowedOrigins() []string {
+	raw := viper.GetStringSlice("allowed_origins")
+	if len(raw) == 1 {
+		raw = strings.Split(raw[0], ",")
+	}
+
+	origins := make([]string, 0, len(raw))
+	for _, o := range raw {
+		o = strings.TrimSpace(o)
+		if o != "" {
+			origins = append(origins, o)
+		}
+	}
+
+	return origins
+}
+
+// checkOrigin implements websocket.Upgrader's CheckOrigin hook. It allows the
+// request through when no Origin header is present (same-origin requests
+// from non-browser clients), when the Origin matches the request's own host
+// (the same same-origin default Gorilla applies without a CheckOrigin), when
+// the allow-list contains the wildcard, or when the Origin's host matches
+// one of the configured glob patterns.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		log.WithFields(log.Fields{"origin": origin}).Warn("could not parse origin header")
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, pattern := range s.allowedOrigins {
+		if pattern == allowedOriginsWildcard {
+			if s.debug {
+				log.WithFields(log.Fields{"origin": origin}).Debug("allowing origin via wildcard")
+			}
+			return true
+		}
+
+		if matched, err := filepath.Match(pattern, u.Host); err == nil && matched {
+			return true
+		}
+	}
+
+	log.WithFields(log.Fields{"origin": origin, "allowed_origins": s.allowedOrigins}).Warn("rejected websocket upgrade from disallowed origin")
+	return false
+}
+
 // ServeMux returns a mux that can be used with the listen and server methods in net/http
 func (s *Server) ServeMux() *http.ServeMux {
 	m := http.NewServeMux()
@@ -125,6 +238,8 @@ func (s *Server) ServeMux() *http.ServeMux {
 	m.HandleFunc("/r/", s.roomHandler)
 	m.HandleFunc("/ws", s.wsHandler)
 	m.HandleFunc("/create", s.createRoomHandler)
+	m.HandleFunc(apiRoomsPath, s.apiRoomsHandler)
+	m.HandleFunc(apiRoomsPath+"/", s.apiRoomHandler)
 	m.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.staticDir))))
 	m.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, s.staticDir+"/favicon.ico")
@@ -148,7 +263,7 @@ func (s *Server) createRoomHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	defaultDeck := r.PostFormValue("deck")
-	if err := s.createGameIfNotExists(room, defaultDeck); err != nil {
+	if _, err := s.createGameIfNotExists(room, defaultDeck); err != nil {
 		if err == game.ErrInvalidRoomName {
 			http.Redirect(w, r, "/?invalid", http.StatusSeeOther)
 			return
@@ -191,6 +306,7 @@ func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
 	room := r.FormValue("room")
 	token := r.FormValue("token")
+	clientToken := r.FormValue("clientToken")
 
 	g := s.getGameByRoom(room)
 	if g == nil {
@@ -203,13 +319,20 @@ func (s *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Errorf("could not upgrade connection: %v", err)
 		return
 	}
 
-	client := NewClient(g, conn, g.NextClientID())
+	client, resumed := g.ResumeClient(clientToken)
+	if resumed {
+		log.WithFields(log.Fields{"room": room, "client": client.ID}).Info("client reconnected")
+		client.Rebind(conn)
+	} else {
+		client = NewClient(g, conn, g.NextClientID())
+	}
+
 	g.RegisterClient(client)
 	defer func() {
 		g.UnregisterClient(client)
@@ -234,6 +357,7 @@ func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 	token = g.Token
 
 	deckJSON, _ := json.Marshal(deck.AllDecks)
+	chatHistoryJSON, _ := json.Marshal(g.ChatHistory())
 
 	decks := make([]string, 0, len(deck.AllDecks))
 	for d := range deck.AllDecks {
@@ -249,29 +373,76 @@ func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
 		DecksJSON:         template.JS(string(deckJSON)),
 		TopicMaxLength:    game.TopicMaxLength,
 		UsernameMaxLength: UsernameMaxLength,
+		ChatHistoryJSON:   template.JS(string(chatHistoryJSON)),
 	}
 	s.templates["room"].ExecuteTemplate(w, "template.html", &values)
 }
 
 func (s *Server) getGameByRoom(room string) *game.Game {
 	s.safeGames.mutex.RLock()
-	defer s.safeGames.mutex.RUnlock()
-
 	if g, found := s.safeGames.games[s.roomKey(room)]; found {
+		s.safeGames.mutex.RUnlock()
 		return g
 	}
+	s.safeGames.mutex.RUnlock()
 
-	return nil
-}
+	if s.store == nil || !game.RoomNameIsValid(room) {
+		return nil
+	}
 
-func (s *Server) createGameIfNotExists(room, defaultDeck string) error {
-	if s.getGameByRoom(room) != nil {
+	g, err := s.loadGame(room)
+	if err != nil {
 		return nil
 	}
 
+	return g
+}
+
+// loadGame loads room from the store and registers it in memory, returning
+// the in-memory game if another goroutine already raced it in.
+func (s *Server) loadGame(room string) (*game.Game, error) {
+	g, err := s.store.Load(room)
+	if err != nil {
+		return nil, err
+	}
+	g.SetDestroyChannel(s.destroyGame)
+
+	roomKey := s.roomKey(room)
+	s.safeGames.mutex.Lock()
+	defer s.safeGames.mutex.Unlock()
+
+	if existing, found := s.safeGames.games[roomKey]; found {
+		return existing, nil
+	}
+	s.safeGames.games[roomKey] = g
+
+	return g, nil
+}
+
+// saveGame persists g's current state, logging but not failing the request
+// if the store is unavailable or the write fails.
+func (s *Server) saveGame(g *game.Game) {
+	if s.store == nil {
+		return
+	}
+
+	if err := s.store.Save(g); err != nil {
+		log.WithFields(log.Fields{"room": g.Room}).Errorf("could not save room: %v", err)
+	}
+}
+
+// createGameIfNotExists returns the existing game for room, creating (and
+// registering) a new one with defaultDeck if none exists yet. Callers that
+// need the *game.Game should use the returned value rather than looking it
+// up again, since a concurrent destroy could otherwise race the lookup.
+func (s *Server) createGameIfNotExists(room, defaultDeck string) (*game.Game, error) {
+	if g := s.getGameByRoom(room); g != nil {
+		return g, nil
+	}
+
 	g, err := game.New(room, defaultDeck, s.destroyGame)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	log.WithFields(log.Fields{"room": g.Room, "token": g.Token}).Info("room created")
@@ -279,7 +450,9 @@ func (s *Server) createGameIfNotExists(room, defaultDeck string) error {
 	s.safeGames.games[s.roomKey(room)] = g
 	s.safeGames.mutex.Unlock()
 
-	return nil
+	s.saveGame(g)
+
+	return g, nil
 }
 
 func (s *Server) roomKey(room string) string {
@@ -319,9 +492,16 @@ func (s *Server) HandleWsRequest(c *Client, r *WsRequest) {
 	case WsRequestActionUsername:
 		c.SetName(r.Value)
 		c.Game.SendUpdate()
+	case WsRequestActionChat:
+		c.Game.AddChatMessage(c, r.Value)
+	case WsRequestActionReaction:
+		c.Game.AddReaction(c, r.Value)
 	default:
 		log.Errorf("unknown action received via ws: %s", r.Action)
+		return
 	}
+
+	s.saveGame(c.Game)
 }
 
 // ListenForEvents will listen for various events like when to destroy a game, and when to disconnect the server.
@@ -339,6 +519,12 @@ func (s *Server) ListenForEvents(done chan bool) {
 				log.WithFields(log.Fields{"room": game.Room, "token": game.Token}).Info("room destroyed")
 			}
 			s.safeGames.mutex.Unlock()
+
+			if s.store != nil {
+				if err := s.store.Delete(game.Room); err != nil {
+					log.WithFields(log.Fields{"room": game.Room}).Errorf("could not delete saved room: %v", err)
+				}
+			}
 		case theSig := <-sig:
 			if theSig == syscall.SIGUSR1 {
 				s.safeGames.mutex.RLock()
@@ -358,6 +544,8 @@ func (s *Server) ListenForEvents(done chan bool) {
 				}
 				s.safeGames.mutex.RUnlock()
 			} else {
+				log.Printf("Shutting down, draining websocket clients...")
+				s.drainClients()
 				log.Printf("Shut down.")
 				done <- true
 				return
@@ -366,6 +554,38 @@ func (s *Server) ListenForEvents(done chan bool) {
 	}
 }
 
+// drainClients broadcasts a shutdown notice to every registered client
+// across all games, gives writers a grace period to flush it, then cancels
+// s.shutdownCtx so WritePump/ReadPump close their connections cleanly.
+func (s *Server) drainClients() {
+	msg, err := json.Marshal(&clientShutdownMessage{
+		Type:               clientMessageTypeShutdown,
+		Reason:             "the server is restarting",
+		ReconnectAfterSecs: 5,
+	})
+	if err != nil {
+		log.Errorf("could not marshal shutdown message: %v", err)
+	} else {
+		s.safeGames.mutex.RLock()
+		for _, g := range s.safeGames.games {
+			for _, c := range g.Clients() {
+				c.Write(msg)
+			}
+		}
+		s.safeGames.mutex.RUnlock()
+	}
+
+	timeout := viper.GetDuration("shutdown_timeout")
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	log.WithFields(log.Fields{"timeout": timeout}).Info("waiting for clients to drain")
+	time.Sleep(timeout)
+
+	s.shutdownCancel()
+}
+
 func newTemplateLoader(templatesDir, baseTemplate string) *templateLoader {
 	base := template.Must(template.ParseFiles(fmt.Sprintf("%s/%s", templatesDir, baseTemplate)))
 
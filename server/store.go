@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/synacor/sibyl/game"
+)
+
+// errInvalidRoom is returned when a room name isn't safe to use as a path
+// component, e.g. it contains a path separator or "..".
+var errInvalidRoom = fmt.Errorf("invalid room name")
+
+const defaultStoreDir = "./data"
+
+// Store persists game state across restarts so rooms can be rehydrated
+// without losing in-progress estimation.
+type Store interface {
+	// Save writes the current state of g to the store.
+	Save(g *game.Game) error
+	// Load reads the state previously saved for room, or returns an error if
+	// no saved state exists.
+	Load(room string) (*game.Game, error)
+	// Delete removes any saved state for room.
+	Delete(room string) error
+	// Rooms returns the room names that currently have saved state.
+	Rooms() ([]string, error)
+}
+
+// gameSnapshot is the on-disk representation of a game.Game, used by
+// FileStore to serialize and restore room state.
+type gameSnapshot struct {
+	Room       string             `json:"room"`
+	Token      string             `json:"token"`
+	Deck       string             `json:"deck"`
+	Topic      string             `json:"topic"`
+	Revealed   bool               `json:"revealed"`
+	Selections map[string]int     `json:"selections"`
+	Chat       []game.ChatMessage `json:"chat"`
+}
+
+// FileStore is a Store that snapshots each game to a JSON file under Dir.
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore returns a *FileStore that snapshots games under dir, creating
+// the directory if it does not already exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		dir = defaultStoreDir
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create store directory: %w", err)
+	}
+
+	return &FileStore{Dir: dir}, nil
+}
+
+// Save writes a snapshot of g to its room file.
+func (fs *FileStore) Save(g *game.Game) error {
+	snapshot := gameSnapshot{
+		Room:       g.Room,
+		Token:      g.Token,
+		Deck:       g.DeckName(),
+		Topic:      g.Topic(),
+		Revealed:   g.Revealed(),
+		Selections: g.Selections(),
+		Chat:       g.ChatHistory(),
+	}
+
+	b, err := json.Marshal(&snapshot)
+	if err != nil {
+		return fmt.Errorf("could not marshal game snapshot: %w", err)
+	}
+
+	path, err := fs.roomPath(g.Room)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("could not write game snapshot: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Load restores the game previously saved for room.
+func (fs *FileStore) Load(room string) (*game.Game, error) {
+	path, err := fs.roomPath(room)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot gameSnapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("could not unmarshal game snapshot: %w", err)
+	}
+
+	return game.Restore(snapshot.Room, snapshot.Token, snapshot.Deck, snapshot.Topic, snapshot.Revealed, snapshot.Selections, snapshot.Chat)
+}
+
+// Delete removes any saved state for room.
+func (fs *FileStore) Delete(room string) error {
+	path, err := fs.roomPath(room)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Rooms returns the room names with a saved snapshot on disk.
+func (fs *FileStore) Rooms() ([]string, error) {
+	entries, err := os.ReadDir(fs.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		rooms = append(rooms, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+
+	return rooms, nil
+}
+
+// roomPath builds the snapshot file path for room, rejecting any room name
+// that could escape fs.Dir (path separators or ".." segments) rather than
+// letting it reach the filesystem.
+func (fs *FileStore) roomPath(room string) (string, error) {
+	if room == "" || strings.ContainsAny(room, `/\`) || strings.Contains(room, "..") {
+		return "", errInvalidRoom
+	}
+
+	return filepath.Join(fs.Dir, strings.ToLower(room)+".json"), nil
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/synacor/sibyl/game"
+)
+
+func TestFileStoreRoomPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		room    string
+		want    string
+		wantErr bool
+	}{
+		{name: "lowercases the room name", dir: "/data", room: "FooBar", want: "/data/foobar.json"},
+		{name: "already lowercase", dir: "/data", room: "foobar", want: "/data/foobar.json"},
+		{name: "empty room is rejected", dir: "/data", room: "", wantErr: true},
+		{name: "parent directory traversal is rejected", dir: "/data", room: "../../../../etc/passwd", wantErr: true},
+		{name: "forward slash is rejected", dir: "/data", room: "foo/bar", wantErr: true},
+		{name: "backslash is rejected", dir: "/data", room: `foo\bar`, wantErr: true},
+		{name: "embedded dotdot is rejected", dir: "/data", room: "foo..bar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &FileStore{Dir: tt.dir}
+			got, err := fs.roomPath(tt.room)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("roomPath(%q) = %q, <nil>, want an error", tt.room, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("roomPath(%q) returned an unexpected error: %v", tt.room, err)
+			}
+			if got != tt.want {
+				t.Errorf("roomPath(%q) = %q, want %q", tt.room, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreDeleteNonExistentIsNotAnError(t *testing.T) {
+	fs := &FileStore{Dir: t.TempDir()}
+
+	if err := fs.Delete("no-such-room"); err != nil {
+		t.Errorf("Delete of a non-existent room returned an error: %v", err)
+	}
+}
+
+func TestFileStoreDeleteRemovesSnapshot(t *testing.T) {
+	fs := &FileStore{Dir: t.TempDir()}
+	path, err := fs.roomPath("my-room")
+	if err != nil {
+		t.Fatalf("roomPath returned an unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatalf("could not seed snapshot file: %v", err)
+	}
+
+	if err := fs.Delete("my-room"); err != nil {
+		t.Fatalf("Delete returned an error: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected snapshot file to be removed, stat err = %v", err)
+	}
+}
+
+func TestFileStoreLoadRejectsPathTraversal(t *testing.T) {
+	fs := &FileStore{Dir: t.TempDir()}
+
+	if _, err := fs.Load("../../../../etc/passwd"); err == nil {
+		t.Error("Load with a path-traversal room name should have returned an error")
+	}
+}
+
+func TestFileStoreRoomsListsSavedSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	fs := &FileStore{Dir: dir}
+
+	for _, room := range []string{"alpha.json", "beta.json", "not-json.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, room), []byte("{}"), 0644); err != nil {
+			t.Fatalf("could not seed %s: %v", room, err)
+		}
+	}
+
+	rooms, err := fs.Rooms()
+	if err != nil {
+		t.Fatalf("Rooms returned an error: %v", err)
+	}
+
+	want := map[string]bool{"alpha": true, "beta": true}
+	if len(rooms) != len(want) {
+		t.Fatalf("Rooms() = %v, want entries for %v", rooms, want)
+	}
+	for _, room := range rooms {
+		if !want[room] {
+			t.Errorf("Rooms() included unexpected entry %q", room)
+		}
+	}
+}
+
+func TestGameSnapshotJSONRoundTrip(t *testing.T) {
+	snapshot := gameSnapshot{
+		Room:       "my-room",
+		Token:      "tok-123",
+		Deck:       "fibonacci",
+		Topic:      "what should we estimate",
+		Revealed:   true,
+		Selections: map[string]int{"client-a": 5, "client-b": 8},
+		Chat:       []game.ChatMessage{},
+	}
+
+	b, err := json.Marshal(&snapshot)
+	if err != nil {
+		t.Fatalf("could not marshal snapshot: %v", err)
+	}
+
+	var restored gameSnapshot
+	if err := json.Unmarshal(b, &restored); err != nil {
+		t.Fatalf("could not unmarshal snapshot: %v", err)
+	}
+
+	if restored.Room != snapshot.Room || restored.Token != snapshot.Token || restored.Deck != snapshot.Deck ||
+		restored.Topic != snapshot.Topic || restored.Revealed != snapshot.Revealed {
+		t.Errorf("restored snapshot = %+v, want %+v", restored, snapshot)
+	}
+	if restored.Selections["client-a"] != 5 || restored.Selections["client-b"] != 8 {
+		t.Errorf("restored selections = %v, want %v", restored.Selections, snapshot.Selections)
+	}
+}